@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHmacAuthMiddlewareExcludesCapabilityURLs(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := hmacAuthMiddleware("secret", inner)
+
+	for _, path := range []string{"/healthz", "/media/tok", "/ws"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("path %s: expected pass-through without signature, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestHmacAuthMiddlewareRejectsMissingSignature(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := hmacAuthMiddleware("secret", inner)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/room/join", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for missing signature, got %d", rec.Code)
+	}
+}
+
+func TestHmacAuthMiddlewareAcceptsValidSignature(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := hmacAuthMiddleware("secret", inner)
+
+	body := []byte(`{"room":"r"}`)
+	random := "nonce"
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(random))
+	mac.Write(body)
+	checksum := hex.EncodeToString(mac.Sum(nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/room/join", bytes.NewReader(body))
+	req.Header.Set(headerSignalingRandom, random)
+	req.Header.Set(headerSignalingChecksum, checksum)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for valid signature, got %d", rec.Code)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -29,6 +31,7 @@ type joinRequest struct {
 type joinResponse struct {
 	TempUser string `json:"tempUser"`
 	Role     string `json:"role"`
+	Ticket   string `json:"ticket"`
 }
 
 type mediaResolveRequest struct {
@@ -38,6 +41,13 @@ type mediaResolveRequest struct {
 	Path     string `json:"path"`
 }
 
+type mediaResolveRemoteRequest struct {
+	Room     string `json:"room"`
+	Password string `json:"password"`
+	TempUser string `json:"tempUser"`
+	URL      string `json:"url"`
+}
+
 type mediaResolveResponse struct {
 	Token     string `json:"token"`
 	URL       string `json:"url"`
@@ -45,31 +55,79 @@ type mediaResolveResponse struct {
 }
 
 type wsIncoming struct {
-	Type  string           `json:"type"`
-	State *rooms.RoomState `json:"state,omitempty"`
+	Type    string                `json:"type"`
+	State   *rooms.RoomState      `json:"state,omitempty"`
+	Text    string                `json:"text,omitempty"`
+	Danmaku *rooms.DanmakuMessage `json:"danmaku,omitempty"`
+	Target  string                `json:"target,omitempty"`
+	SDP     json.RawMessage       `json:"sdp,omitempty"`
+	Perms   []string              `json:"perms,omitempty"`
 }
 
 type wsOutgoing struct {
-	Type  string           `json:"type"`
-	State *rooms.RoomState `json:"state,omitempty"`
-	Error string           `json:"error,omitempty"`
+	Type    string                `json:"type"`
+	State   *rooms.RoomState      `json:"state,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Chat    *rooms.ChatMessage    `json:"chat,omitempty"`
+	Danmaku *rooms.DanmakuMessage `json:"danmaku,omitempty"`
+	SDP     json.RawMessage       `json:"sdp,omitempty"`
+	From    string                `json:"from,omitempty"`
+	Target  string                `json:"target,omitempty"`
+	Perms   []string              `json:"perms,omitempty"`
+	NewHost string                `json:"newHost,omitempty"`
+}
+
+type rtcConfigResponse struct {
+	ICEServers []rooms.ICEServer `json:"iceServers"`
 }
 
 func main() {
 	var (
-		addr      = flag.String("addr", envOrDefault("SERVER_ADDR", ":8080"), "http listen address")
-		mediaRoot = flag.String("media_root", os.Getenv("MEDIA_ROOT"), "media root directory")
+		addr           = flag.String("addr", envOrDefault("SERVER_ADDR", ":8080"), "http listen address")
+		mediaRoot      = flag.String("media_root", os.Getenv("MEDIA_ROOT"), "media root directory")
+		stunURLs       = flag.String("stun_urls", os.Getenv("STUN_URLS"), "comma-separated STUN server URLs")
+		turnURLs       = flag.String("turn_urls", os.Getenv("TURN_URLS"), "comma-separated TURN server URLs")
+		turnUsername   = flag.String("turn_username", os.Getenv("TURN_USERNAME"), "TURN username")
+		turnCredential = flag.String("turn_credential", os.Getenv("TURN_CREDENTIAL"), "TURN credential")
+		sharedSecret   = flag.String("shared_secret", os.Getenv("SHARED_SECRET"), "hmac shared secret for backend API authentication (disabled if empty)")
+		ticketTTL      = flag.Duration("ticket_ttl", 30*time.Second, "ttl for websocket join tickets")
+		boltPath       = flag.String("bolt_path", os.Getenv("BOLT_PATH"), "path to bbolt database file for room persistence (in-memory only if empty)")
+		hostGrace      = flag.Duration("host_grace_period", 20*time.Second, "grace period before an automatic host handoff after the host disconnects")
+		mediaCacheSize = flag.Int64("media_proxy_cache_bytes", envOrDefaultInt64("MEDIA_PROXY_CACHE_BYTES", 0), "disk cache size cap in bytes for the remote media proxy (uses package default if 0)")
 	)
 	flag.Parse()
 
-	manager := rooms.NewManager(*mediaRoot)
-	hub := NewHub(manager)
+	// Hub 需要持有 manager 才能广播，而 manager 的 WithHostChangeHandler 选项又
+	// 需要引用 hub 的方法，因此先构造一个空壳 Hub，再在 manager 就绪后补上引用
+	hub := NewHub(nil)
+
+	managerOpts := []rooms.Option{
+		rooms.WithICEServers(parseICEServers(*stunURLs, *turnURLs, *turnUsername, *turnCredential)),
+		rooms.WithTicketTTL(*ticketTTL),
+		rooms.WithHostGracePeriod(*hostGrace),
+		rooms.WithHostChangeHandler(hub.handleHostChanged),
+	}
+	if *mediaCacheSize > 0 {
+		managerOpts = append(managerOpts, rooms.WithMediaProxyCacheSize(*mediaCacheSize))
+	}
+	if *boltPath != "" {
+		store, err := rooms.NewBoltStore(*boltPath)
+		if err != nil {
+			log.Fatalf("open bolt store: %v", err)
+		}
+		managerOpts = append(managerOpts, rooms.WithStore(store))
+	}
+	manager := rooms.NewManager(*mediaRoot, managerOpts...)
+	hub.manager = manager
 
 	mux := http.NewServeMux()
 	mux.Handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	}))
+	mux.Handle("/api/room/rtc-config", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, rtcConfigResponse{ICEServers: manager.ICEServers()})
+	}))
 	mux.Handle("/api/room/join", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			methodNotAllowed(w)
@@ -80,14 +138,24 @@ func main() {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		tempUser, isHost, err := manager.JoinRoom(req.Room, req.Password)
+		tempUser, _, err := manager.JoinRoom(req.Room, req.Password)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
+		role := "member"
+		if manager.HasPermission(req.Room, tempUser, rooms.PermControlPlayback) {
+			role = "host"
+		}
+		ticket, err := manager.IssueTicket(req.Room, tempUser)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
 		resp := joinResponse{
 			TempUser: tempUser,
-			Role:     map[bool]string{true: "host", false: "member"}[isHost],
+			Role:     role,
+			Ticket:   ticket,
 		}
 		writeJSON(w, http.StatusOK, resp)
 	}))
@@ -117,28 +185,77 @@ func main() {
 		}
 		writeJSON(w, http.StatusOK, resp)
 	}))
+	mux.Handle("/api/media/resolve_remote", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		var req mediaResolveRemoteRequest
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.URL == "" {
+			writeError(w, http.StatusBadRequest, errors.New("url required"))
+			return
+		}
+		token, err := manager.ResolveRemoteURL(req.Room, req.TempUser, req.URL)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		resp := mediaResolveResponse{
+			Token:     token,
+			URL:       "/media/" + token,
+			ExpiresAt: time.Now().Add(time.Hour).UnixMilli(),
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}))
 	mux.Handle("/media/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		token := strings.TrimPrefix(r.URL.Path, "/media/")
 		if token == "" {
 			http.NotFound(w, r)
 			return
 		}
-		path, _, err := manager.OpenMedia(token)
-		if err != nil {
-			http.NotFound(w, r)
+		if path, _, err := manager.OpenMedia(token); err == nil {
+			http.ServeFile(w, r, path)
 			return
 		}
-		http.ServeFile(w, r, path)
+		if upstreamURL, _, err := manager.OpenRemoteMedia(token); err == nil {
+			if err := manager.MediaProxy().Serve(w, r, upstreamURL); err != nil {
+				log.Println("media proxy error:", err)
+				http.Error(w, "upstream fetch failed", http.StatusBadGateway)
+			}
+			return
+		}
+		http.NotFound(w, r)
 	}))
 	mux.Handle("/ws", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		roomName := r.URL.Query().Get("room")
-		password := r.URL.Query().Get("password")
 		tempUser := r.URL.Query().Get("tempUser")
-		if roomName == "" || password == "" || tempUser == "" {
-			http.Error(w, "room, password, tempUser required", http.StatusBadRequest)
-			return
+
+		var isHost bool
+		var err error
+		if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+			var ticketRoom, ticketUser string
+			ticketRoom, ticketUser, err = manager.RedeemTicket(ticket)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if ticketRoom != roomName || ticketUser != tempUser {
+				http.Error(w, "ticket does not match request", http.StatusForbidden)
+				return
+			}
+			isHost, err = manager.AuthorizeTicket(roomName, tempUser)
+		} else {
+			password := r.URL.Query().Get("password")
+			if roomName == "" || password == "" || tempUser == "" {
+				http.Error(w, "room, password, tempUser required", http.StatusBadRequest)
+				return
+			}
+			isHost, err = manager.Authorize(roomName, password, tempUser)
 		}
-		isHost, err := manager.Authorize(roomName, password, tempUser)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusForbidden)
 			return
@@ -159,15 +276,58 @@ func main() {
 		if state := manager.CurrentState(roomName); state != nil {
 			client.sendJSON(wsOutgoing{Type: "room_state", State: state})
 		}
+		for _, msg := range manager.ChatHistory(roomName) {
+			msg := msg
+			client.sendJSON(wsOutgoing{Type: "chat", Chat: &msg})
+		}
 		go client.readLoop()
 	}))
 
 	log.Printf("mobile sync server listening on %s", *addr)
-	if err := http.ListenAndServe(*addr, corsMiddleware(mux)); err != nil {
+	handler := corsMiddleware(hmacAuthMiddleware(*sharedSecret, mux))
+	if err := http.ListenAndServe(*addr, handler); err != nil {
 		log.Fatal(err)
 	}
 }
 
+func parsePermissions(names []string) (rooms.Permissions, error) {
+	var perms rooms.Permissions
+	for _, name := range names {
+		p, ok := rooms.ParsePermission(name)
+		if !ok {
+			return 0, fmt.Errorf("unknown permission %q", name)
+		}
+		perms |= p
+	}
+	return perms, nil
+}
+
+func parseICEServers(stunURLs, turnURLs, turnUsername, turnCredential string) []rooms.ICEServer {
+	var servers []rooms.ICEServer
+	if urls := splitNonEmpty(stunURLs); len(urls) > 0 {
+		servers = append(servers, rooms.ICEServer{URLs: urls})
+	}
+	if urls := splitNonEmpty(turnURLs); len(urls) > 0 {
+		servers = append(servers, rooms.ICEServer{
+			URLs:       urls,
+			Username:   turnUsername,
+			Credential: turnCredential,
+		})
+	}
+	return servers
+}
+
+func splitNonEmpty(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 func envOrDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -175,6 +335,18 @@ func envOrDefault(key, fallback string) string {
 	return fallback
 }
 
+func envOrDefaultInt64(key string, fallback int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
 func decodeJSON(r *http.Request, v interface{}) error {
 	defer r.Body.Close()
 	decoder := json.NewDecoder(r.Body)
@@ -237,11 +409,11 @@ func (h *Hub) broadcastState(roomName string, state *rooms.RoomState) {
 	if state == nil {
 		return
 	}
-	stateCopy := cloneState(state)
-	payload, err := json.Marshal(wsOutgoing{
-		Type:  "room_state",
-		State: stateCopy,
-	})
+	h.broadcast(roomName, wsOutgoing{Type: "room_state", State: cloneState(state)})
+}
+
+func (h *Hub) broadcast(roomName string, msg wsOutgoing) {
+	payload, err := json.Marshal(msg)
 	if err != nil {
 		log.Println("broadcast marshal error:", err)
 		return
@@ -253,19 +425,101 @@ func (h *Hub) broadcastState(roomName string, state *rooms.RoomState) {
 	}
 }
 
+// fanoutToViewers 把发布者的 offer 转发给房间内除发布者自己以外的所有成员
+func (h *Hub) fanoutToViewers(roomName, publisher string, msg wsOutgoing) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		log.Println("fanout marshal error:", err)
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients[roomName] {
+		if client.tempUser != publisher {
+			client.send(payload)
+		}
+	}
+}
+
+// handleHostChanged 是 rooms.HostChangeFunc 的实现，在自动房主交接完成后
+// 把新房主 ID 广播给房间内所有连接，并翻转受影响客户端的 isHost 标记
+func (h *Hub) handleHostChanged(roomName, newHostID string) {
+	h.mu.RLock()
+	for client := range h.clients[roomName] {
+		client.setHost(client.tempUser == newHostID)
+	}
+	h.mu.RUnlock()
+	h.broadcast(roomName, wsOutgoing{Type: "host_changed", NewHost: newHostID})
+}
+
+// sendToMember 按 tempUser 把一条消息单独发给房间内的某个成员，用于成员回给发布者的
+// answer、双向的 ICE 候选以及权限变更通知
+func (h *Hub) sendToMember(roomName, tempUser string, msg wsOutgoing) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		log.Println("send to member marshal error:", err)
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients[roomName] {
+		if client.tempUser == tempUser {
+			client.send(payload)
+			return
+		}
+	}
+}
+
+// kickMember 把踢出通知发给目标成员并主动断开其连接，断开会触发 Client.readLoop
+// 的清理逻辑（移除 client、清理流状态）
+func (h *Hub) kickMember(roomName, tempUser string) {
+	h.mu.RLock()
+	var targets []*Client
+	for client := range h.clients[roomName] {
+		if client.tempUser == tempUser {
+			targets = append(targets, client)
+		}
+	}
+	h.mu.RUnlock()
+	for _, client := range targets {
+		client.sendJSON(wsOutgoing{Type: "kicked"})
+		client.conn.Close()
+	}
+}
+
 type Client struct {
 	conn     *websocket.Conn
 	hub      *Hub
 	roomName string
 	tempUser string
-	isHost   bool
+
+	hostMu sync.RWMutex
+	isHost bool
 
 	writeMu sync.Mutex
 }
 
+// IsHost 是 isHost 的加锁读取：自动房主交接会从计时器所在的 goroutine
+// 翻转这个标记，而 readLoop 在客户端自己的 goroutine 里读取它，二者需要同步
+func (c *Client) IsHost() bool {
+	c.hostMu.RLock()
+	defer c.hostMu.RUnlock()
+	return c.isHost
+}
+
+func (c *Client) setHost(isHost bool) {
+	c.hostMu.Lock()
+	c.isHost = isHost
+	c.hostMu.Unlock()
+}
+
 func (c *Client) readLoop() {
 	defer func() {
 		c.hub.removeClient(c)
+		c.hub.manager.ClearStream(c.roomName, c.tempUser)
+		if c.IsHost() {
+			c.hub.manager.DisconnectHost(c.roomName, c.tempUser)
+		}
 		c.conn.Close()
 	}()
 	for {
@@ -278,10 +532,6 @@ func (c *Client) readLoop() {
 		}
 		switch msg.Type {
 		case "host_update":
-			if !c.isHost {
-				c.sendJSON(wsOutgoing{Type: "error", Error: "only host can update"})
-				continue
-			}
 			state, err := c.hub.manager.UpdateState(c.roomName, c.tempUser, msg.State)
 			if err != nil {
 				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
@@ -290,6 +540,109 @@ func (c *Client) readLoop() {
 			c.hub.broadcastState(c.roomName, state)
 		case "member_ping":
 			c.hub.manager.TouchMember(c.roomName, c.tempUser)
+		case "chat":
+			chatMsg, err := c.hub.manager.SendChat(c.roomName, c.tempUser, msg.Text)
+			if err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			c.hub.broadcast(c.roomName, wsOutgoing{Type: "chat", Chat: chatMsg})
+		case "danmaku":
+			danmaku, err := c.hub.manager.SendDanmaku(c.roomName, c.tempUser, msg.Danmaku)
+			if err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			c.hub.broadcast(c.roomName, wsOutgoing{Type: "danmaku", Danmaku: danmaku})
+		case "mute_member", "unmute_member":
+			if !c.IsHost() {
+				c.sendJSON(wsOutgoing{Type: "error", Error: "only host can mute members"})
+				continue
+			}
+			if err := c.hub.manager.MuteMember(c.roomName, c.tempUser, msg.Target, msg.Type == "mute_member"); err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+		case "kick_member":
+			if err := c.hub.manager.Kick(c.roomName, c.tempUser, msg.Target); err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			c.hub.manager.ClearStream(c.roomName, msg.Target)
+			c.hub.kickMember(c.roomName, msg.Target)
+		case "grant_permissions":
+			if !c.IsHost() {
+				c.sendJSON(wsOutgoing{Type: "error", Error: "only host can modify permissions"})
+				continue
+			}
+			perms, err := parsePermissions(msg.Perms)
+			if err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			if err := c.hub.manager.GrantPermissions(c.roomName, c.tempUser, msg.Target, perms); err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			c.hub.sendToMember(c.roomName, msg.Target, wsOutgoing{Type: "permissions_granted", Target: msg.Target, Perms: msg.Perms})
+		case "revoke_permissions":
+			if !c.IsHost() {
+				c.sendJSON(wsOutgoing{Type: "error", Error: "only host can modify permissions"})
+				continue
+			}
+			perms, err := parsePermissions(msg.Perms)
+			if err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			if _, err := c.hub.manager.RevokePermissions(c.roomName, c.tempUser, msg.Target, perms); err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			if perms.Has(rooms.PermShareMedia) {
+				c.hub.manager.ClearStream(c.roomName, msg.Target)
+			}
+			c.hub.sendToMember(c.roomName, msg.Target, wsOutgoing{Type: "permissions_revoked", Target: msg.Target, Perms: msg.Perms})
+		case "webrtc_offer":
+			if !c.hub.manager.HasPermission(c.roomName, c.tempUser, rooms.PermShareMedia) {
+				c.sendJSON(wsOutgoing{Type: "error", Error: "missing can_share_media permission"})
+				continue
+			}
+			if err := c.hub.manager.MarkPublishing(c.roomName, c.tempUser); err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			c.hub.fanoutToViewers(c.roomName, c.tempUser, wsOutgoing{Type: "webrtc_offer", SDP: msg.SDP, From: c.tempUser})
+		case "webrtc_answer":
+			publisher := c.hub.manager.CurrentPublisher(c.roomName)
+			if c.tempUser == publisher {
+				c.sendJSON(wsOutgoing{Type: "error", Error: "publisher does not answer its own offer"})
+				continue
+			}
+			if err := c.hub.manager.MarkSubscribing(c.roomName, c.tempUser); err != nil {
+				c.sendJSON(wsOutgoing{Type: "error", Error: err.Error()})
+				continue
+			}
+			if publisher == "" {
+				c.sendJSON(wsOutgoing{Type: "error", Error: "no active publisher"})
+				continue
+			}
+			c.hub.sendToMember(c.roomName, publisher, wsOutgoing{Type: "webrtc_answer", SDP: msg.SDP, From: c.tempUser})
+		case "webrtc_ice":
+			publisher := c.hub.manager.CurrentPublisher(c.roomName)
+			if c.tempUser == publisher {
+				if msg.Target == "" {
+					c.sendJSON(wsOutgoing{Type: "error", Error: "target required"})
+					continue
+				}
+				c.hub.sendToMember(c.roomName, msg.Target, wsOutgoing{Type: "webrtc_ice", SDP: msg.SDP, From: c.tempUser})
+			} else {
+				if publisher == "" {
+					c.sendJSON(wsOutgoing{Type: "error", Error: "no active publisher"})
+					continue
+				}
+				c.hub.sendToMember(c.roomName, publisher, wsOutgoing{Type: "webrtc_ice", SDP: msg.SDP, From: c.tempUser})
+			}
 		default:
 			c.sendJSON(wsOutgoing{Type: "error", Error: "unknown message type"})
 		}
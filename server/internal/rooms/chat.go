@@ -0,0 +1,184 @@
+package rooms
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	chatHistorySize   = 50
+	chatMessageMaxLen = 500
+	chatRateLimit     = 3 // 每秒补充的令牌数
+	chatRateBurst     = 6 // 令牌桶容量
+)
+
+var (
+	ErrMessageTooLong = errors.New("message too long")
+	ErrRateLimited    = errors.New("rate limit exceeded")
+	ErrMemberMuted    = errors.New("member is muted")
+	ErrInvalidDanmaku = errors.New("invalid danmaku payload")
+)
+
+// ChatMessage 是一条房间内的聊天消息
+type ChatMessage struct {
+	TempUser string `json:"tempUser"`
+	Text     string `json:"text"`
+	SentAt   int64  `json:"sentAt"`
+}
+
+// DanmakuMessage 是一条弹幕，携带播放器渲染所需的样式与时间信息
+type DanmakuMessage struct {
+	TempUser  string  `json:"tempUser"`
+	Text      string  `json:"text"`
+	Color     string  `json:"color"`
+	FontSize  int     `json:"fontSize"`
+	Mode      string  `json:"mode"` // scroll | top | bottom
+	VideoTime float64 `json:"videoTime"`
+	SentAt    int64   `json:"sentAt"`
+}
+
+// tokenBucket 是一个简单的令牌桶限流器
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64 // 每秒补充的令牌数
+	last     time.Time
+}
+
+func newTokenBucket(rate, capacity float64) *tokenBucket {
+	return &tokenBucket{tokens: capacity, capacity: capacity, rate: rate, last: time.Now()}
+}
+
+func (b *tokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SendChat 校验并追加一条聊天消息，返回值可直接广播给房间内其它成员
+func (m *Manager) SendChat(roomName, tempUser, text string) (*ChatMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("text required")
+	}
+	if len(text) > chatMessageMaxLen {
+		return nil, ErrMessageTooLong
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	if _, exists := r.Members[tempUser]; !exists {
+		return nil, errMemberNotInRoom(tempUser)
+	}
+	if !r.hasPermission(tempUser, PermChat) {
+		return nil, ErrPermissionDenied
+	}
+	if r.Muted[tempUser] {
+		return nil, ErrMemberMuted
+	}
+	if !r.limiter(tempUser).Allow() {
+		return nil, ErrRateLimited
+	}
+
+	msg := ChatMessage{TempUser: tempUser, Text: text, SentAt: time.Now().UnixMilli()}
+	r.ChatHistory = append(r.ChatHistory, msg)
+	if len(r.ChatHistory) > chatHistorySize {
+		r.ChatHistory = r.ChatHistory[len(r.ChatHistory)-chatHistorySize:]
+	}
+	return &msg, nil
+}
+
+// SendDanmaku 校验并打上时间戳，但不进入聊天历史（弹幕是即时广播的）
+func (m *Manager) SendDanmaku(roomName, tempUser string, msg *DanmakuMessage) (*DanmakuMessage, error) {
+	if msg == nil {
+		return nil, ErrInvalidDanmaku
+	}
+	text := strings.TrimSpace(msg.Text)
+	if text == "" {
+		return nil, errors.New("text required")
+	}
+	if len(text) > chatMessageMaxLen {
+		return nil, ErrMessageTooLong
+	}
+	switch msg.Mode {
+	case "scroll", "top", "bottom":
+	default:
+		return nil, ErrInvalidDanmaku
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	if _, exists := r.Members[tempUser]; !exists {
+		return nil, errMemberNotInRoom(tempUser)
+	}
+	if !r.hasPermission(tempUser, PermDanmaku) {
+		return nil, ErrPermissionDenied
+	}
+	if r.Muted[tempUser] {
+		return nil, ErrMemberMuted
+	}
+	if !r.limiter(tempUser).Allow() {
+		return nil, ErrRateLimited
+	}
+
+	out := *msg
+	out.TempUser = tempUser
+	out.Text = text
+	out.SentAt = time.Now().UnixMilli()
+	return &out, nil
+}
+
+// ChatHistory 返回最近的聊天记录，供新加入成员回放
+func (m *Manager) ChatHistory(roomName string) []ChatMessage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return nil
+	}
+	out := make([]ChatMessage, len(r.ChatHistory))
+	copy(out, r.ChatHistory)
+	return out
+}
+
+// MuteMember 仅房主可用，切换指定成员的禁言状态
+func (m *Manager) MuteMember(roomName, hostID, target string, muted bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if r.HostID != hostID {
+		return ErrNotHost
+	}
+	if _, exists := r.Members[target]; !exists {
+		return errMemberNotInRoom(target)
+	}
+	if r.Muted == nil {
+		r.Muted = make(map[string]bool)
+	}
+	r.Muted[target] = muted
+	return nil
+}
@@ -0,0 +1,86 @@
+package rooms
+
+import "time"
+
+// HostChangeFunc 在自动房主交接完成后被调用，用来通知 ws hub 广播 host_changed
+type HostChangeFunc func(roomName, newHostID string)
+
+// DisconnectHost 在当前房主的 WebSocket 连接断开时调用，启动一个宽限期计时器：
+// 如果房主在宽限期内没有重新 Authorize（重连），最早加入的剩余成员会被提升为新房主
+func (m *Manager) DisconnectHost(roomName, tempUser string) {
+	m.mu.Lock()
+	r, ok := m.rooms[roomName]
+	if !ok || r.HostID != tempUser {
+		m.mu.Unlock()
+		return
+	}
+	grace := m.hostGrace
+	m.mu.Unlock()
+
+	timer := time.AfterFunc(grace, func() { m.resolveHandoff(roomName, tempUser) })
+
+	m.handoffMu.Lock()
+	if m.pendingHandoffs == nil {
+		m.pendingHandoffs = make(map[string]*time.Timer)
+	}
+	if existing, ok := m.pendingHandoffs[roomName]; ok {
+		existing.Stop()
+	}
+	m.pendingHandoffs[roomName] = timer
+	m.handoffMu.Unlock()
+}
+
+// CancelHandoff 在房主于宽限期内重新连接时调用，取消挂起的交接计时器
+func (m *Manager) CancelHandoff(roomName string) {
+	m.handoffMu.Lock()
+	defer m.handoffMu.Unlock()
+	if t, ok := m.pendingHandoffs[roomName]; ok {
+		t.Stop()
+		delete(m.pendingHandoffs, roomName)
+	}
+}
+
+func (m *Manager) resolveHandoff(roomName, departedHost string) {
+	m.handoffMu.Lock()
+	delete(m.pendingHandoffs, roomName)
+	m.handoffMu.Unlock()
+
+	m.mu.Lock()
+	r, ok := m.rooms[roomName]
+	if !ok || r.HostID != departedHost {
+		m.mu.Unlock()
+		return
+	}
+	newHost := earliestMember(r, departedHost)
+	if newHost == "" {
+		m.mu.Unlock()
+		return
+	}
+	r.HostID = newHost
+	r.Permissions[departedHost] = defaultMemberPermissions
+	r.Permissions[newHost] = AllPermissions
+	m.markDirty(roomName)
+	onChanged := m.onHostChanged
+	m.mu.Unlock()
+
+	if onChanged != nil {
+		onChanged(roomName, newHost)
+	}
+}
+
+// earliestMember 要求调用方已持有 m.mu
+func earliestMember(r *room, exclude string) string {
+	var best string
+	var bestJoinedAt time.Time
+	for user := range r.Members {
+		if user == exclude {
+			continue
+		}
+		joinedAt := r.JoinedAt[user]
+		if best == "" || joinedAt.Before(bestJoinedAt) {
+			best = user
+			bestJoinedAt = joinedAt
+		}
+	}
+	return best
+}
@@ -19,6 +19,10 @@ var (
 	ErrMediaForbidden = errors.New("media path forbidden")
 )
 
+func errMemberNotInRoom(tempUser string) error {
+	return fmt.Errorf("user %s not in room", tempUser)
+}
+
 // RoomState 描述一次同步状态
 type RoomState struct {
 	URL          string  `json:"url"`
@@ -32,12 +36,33 @@ type RoomState struct {
 }
 
 type room struct {
-	Name       string
-	Password   string
-	HostID     string
-	State      *RoomState
-	Members    map[string]time.Time
-	LastUpdate time.Time
+	Name        string
+	Password    string
+	HostID      string
+	State       *RoomState
+	Members     map[string]time.Time
+	JoinedAt    map[string]time.Time
+	LastUpdate  time.Time
+	ChatHistory []ChatMessage
+	Muted       map[string]bool
+	limiters    map[string]*tokenBucket
+	Publishing  map[string]bool
+	Subscribing map[string]bool
+	Publisher   string
+	Permissions map[string]Permissions
+}
+
+// limiter 返回该成员的聊天/弹幕令牌桶，调用方需持有 m.mu
+func (r *room) limiter(tempUser string) *tokenBucket {
+	if r.limiters == nil {
+		r.limiters = make(map[string]*tokenBucket)
+	}
+	lb, ok := r.limiters[tempUser]
+	if !ok {
+		lb = newTokenBucket(chatRateLimit, chatRateBurst)
+		r.limiters[tempUser] = lb
+	}
+	return lb
 }
 
 type mediaToken struct {
@@ -47,28 +72,74 @@ type mediaToken struct {
 	ExpiresAt time.Time
 }
 
+type remoteMediaToken struct {
+	Token     string
+	URL       string
+	RoomName  string
+	ExpiresAt time.Time
+}
+
+const defaultMediaProxyCacheBytes = 512 * 1024 * 1024
+
 // Manager 负责房间、成员、媒体的状态管理
 type Manager struct {
-	mu          sync.RWMutex
-	rooms       map[string]*room
-	mediaTokens map[string]*mediaToken
-	mediaRoot   string
-	roomTTL     time.Duration
-	tokenTTL    time.Duration
+	mu           sync.RWMutex
+	rooms        map[string]*room
+	mediaTokens  map[string]*mediaToken
+	remoteTokens map[string]*remoteMediaToken
+	mediaRoot    string
+	roomTTL      time.Duration
+	tokenTTL     time.Duration
+
+	mediaProxyCacheMax int64
+	mediaProxy         *MediaProxy
+
+	iceServers []ICEServer
+
+	ticketStore *TicketStore
+
+	hostGrace       time.Duration
+	handoffMu       sync.Mutex
+	pendingHandoffs map[string]*time.Timer
+	onHostChanged   HostChangeFunc
+
+	store           Store
+	persistInterval time.Duration
+	dirtyMu         sync.Mutex
+	dirty           map[string]bool
 }
 
-func NewManager(mediaRoot string) *Manager {
+func NewManager(mediaRoot string, opts ...Option) *Manager {
 	m := &Manager{
-		rooms:       make(map[string]*room),
-		mediaTokens: make(map[string]*mediaToken),
-		mediaRoot:   filepath.Clean(mediaRoot),
-		roomTTL:     30 * time.Minute,
-		tokenTTL:    1 * time.Hour,
+		rooms:              make(map[string]*room),
+		mediaTokens:        make(map[string]*mediaToken),
+		remoteTokens:       make(map[string]*remoteMediaToken),
+		mediaRoot:          filepath.Clean(mediaRoot),
+		roomTTL:            30 * time.Minute,
+		tokenTTL:           1 * time.Hour,
+		mediaProxyCacheMax: defaultMediaProxyCacheBytes,
+		ticketStore:        NewTicketStore(30 * time.Second),
+		hostGrace:          20 * time.Second,
+		pendingHandoffs:    make(map[string]*time.Timer),
+		store:              NewMemoryStore(),
+		persistInterval:    defaultPersistInterval,
+		dirty:              make(map[string]bool),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.mediaProxy = NewMediaProxy(filepath.Join(mediaRoot, ".media_proxy_cache"), m.mediaProxyCacheMax)
+	m.loadFromStore()
 	go m.cleanupLoop()
+	go m.persistLoop()
 	return m
 }
 
+// MediaProxy 返回用于转发远程 URL 的代理实例
+func (m *Manager) MediaProxy() *MediaProxy {
+	return m.mediaProxy
+}
+
 // JoinRoom 返回新生成的临时用户 ID 以及是否成为房主
 func (m *Manager) JoinRoom(name, password string) (tempUser string, isHost bool, err error) {
 	name = strings.TrimSpace(name)
@@ -84,10 +155,13 @@ func (m *Manager) JoinRoom(name, password string) (tempUser string, isHost bool,
 	r, ok := m.rooms[name]
 	if !ok {
 		r = &room{
-			Name:     name,
-			Password: password,
-			HostID:   tempUser,
-			Members:  map[string]time.Time{},
+			Name:        name,
+			Password:    password,
+			HostID:      tempUser,
+			Members:     map[string]time.Time{},
+			JoinedAt:    map[string]time.Time{},
+			Muted:       map[string]bool{},
+			Permissions: map[string]Permissions{},
 		}
 		m.rooms[name] = r
 		isHost = true
@@ -101,26 +175,75 @@ func (m *Manager) JoinRoom(name, password string) (tempUser string, isHost bool,
 		}
 	}
 	r.Members[tempUser] = time.Now()
+	if r.JoinedAt == nil {
+		r.JoinedAt = map[string]time.Time{}
+	}
+	r.JoinedAt[tempUser] = time.Now()
+	if r.Permissions == nil {
+		r.Permissions = map[string]Permissions{}
+	}
+	if isHost {
+		r.Permissions[tempUser] = AllPermissions
+	} else {
+		r.Permissions[tempUser] = defaultMemberPermissions
+	}
+	m.markDirty(name)
 	return tempUser, isHost, nil
 }
 
 func (m *Manager) Authorize(roomName, password, tempUser string) (isHost bool, err error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
 	r, ok := m.rooms[roomName]
 	if !ok {
+		m.mu.RUnlock()
 		return false, ErrRoomNotFound
 	}
 	if r.Password != password {
+		m.mu.RUnlock()
 		return false, ErrWrongPassword
 	}
-	_, exists := r.Members[tempUser]
-	if !exists {
-		return false, fmt.Errorf("user %s not in room", tempUser)
+	isHost, err = memberRole(r, tempUser)
+	m.mu.RUnlock()
+	if err == nil && isHost {
+		m.CancelHandoff(roomName)
+	}
+	return isHost, err
+}
+
+// AuthorizeTicket 是 Authorize 的变体，用短期 ticket 取代明文密码完成校验，
+// 供 /ws 在 RedeemTicket 兑换成功后确认调用者身份
+func (m *Manager) AuthorizeTicket(roomName, tempUser string) (isHost bool, err error) {
+	m.mu.RLock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		m.mu.RUnlock()
+		return false, ErrRoomNotFound
+	}
+	isHost, err = memberRole(r, tempUser)
+	m.mu.RUnlock()
+	if err == nil && isHost {
+		m.CancelHandoff(roomName)
+	}
+	return isHost, err
+}
+
+func memberRole(r *room, tempUser string) (isHost bool, err error) {
+	if _, exists := r.Members[tempUser]; !exists {
+		return false, errMemberNotInRoom(tempUser)
 	}
 	return r.HostID == tempUser, nil
 }
 
+// IssueTicket 签发一个短期有效的 WebSocket 接入凭证，避免密码出现在 URL 中
+func (m *Manager) IssueTicket(roomName, tempUser string) (string, error) {
+	return m.ticketStore.Issue(roomName, tempUser)
+}
+
+// RedeemTicket 一次性兑换一个 ticket，返回其绑定的房间与用户
+func (m *Manager) RedeemTicket(token string) (roomName, tempUser string, err error) {
+	return m.ticketStore.Redeem(token)
+}
+
 func (m *Manager) TouchMember(roomName, tempUser string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -139,13 +262,14 @@ func (m *Manager) UpdateState(roomName, tempUser string, state *RoomState) (*Roo
 	if !ok {
 		return nil, ErrRoomNotFound
 	}
-	if r.HostID != tempUser {
-		return nil, ErrNotHost
+	if !r.hasPermission(tempUser, PermControlPlayback) {
+		return nil, ErrPermissionDenied
 	}
 	stateCopy := *state
 	stateCopy.UpdatedAt = time.Now().UnixMilli()
 	r.State = &stateCopy
 	r.LastUpdate = time.Now()
+	m.markDirty(roomName)
 	return r.State, nil
 }
 
@@ -183,9 +307,10 @@ func (m *Manager) ResolveMediaPath(roomName, tempUser, absPath string) (token st
 	if !ok {
 		return "", ErrRoomNotFound
 	}
-	if r.HostID != tempUser {
-		return "", ErrNotHost
+	if !r.hasPermission(tempUser, PermShareMedia) {
+		return "", ErrPermissionDenied
 	}
+	m.markDirty(roomName)
 
 	token = uuid.NewString()
 	m.mediaTokens[token] = &mediaToken{
@@ -210,6 +335,54 @@ func (m *Manager) OpenMedia(token string) (path string, roomName string, err err
 	return t.Path, t.RoomName, nil
 }
 
+// ResolveRemoteURL 让持有 can_share_media 权限的成员（房主默认持有）把一个远程 URL（如 bilibili/YouTube 的直链）登记为可被
+// 房间成员通过 /media/{token} 代理访问的资源，解决受限网络下无法直连上游的问题
+func (m *Manager) ResolveRemoteURL(roomName, tempUser, remoteURL string) (token string, err error) {
+	remoteURL = strings.TrimSpace(remoteURL)
+	if remoteURL == "" {
+		return "", errors.New("url required")
+	}
+	if !strings.HasPrefix(remoteURL, "http://") && !strings.HasPrefix(remoteURL, "https://") {
+		return "", errors.New("only http(s) urls are supported")
+	}
+	if err := validateRemoteURL(remoteURL); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return "", ErrRoomNotFound
+	}
+	if !r.hasPermission(tempUser, PermShareMedia) {
+		return "", ErrPermissionDenied
+	}
+
+	token = uuid.NewString()
+	m.remoteTokens[token] = &remoteMediaToken{
+		Token:     token,
+		URL:       remoteURL,
+		RoomName:  roomName,
+		ExpiresAt: time.Now().Add(m.tokenTTL),
+	}
+	m.markDirty(roomName)
+	return token, nil
+}
+
+func (m *Manager) OpenRemoteMedia(token string) (remoteURL string, roomName string, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.remoteTokens[token]
+	if !ok {
+		return "", "", errors.New("token not found")
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return "", "", errors.New("token expired")
+	}
+	return t.URL, t.RoomName, nil
+}
+
 func (m *Manager) cleanupLoop() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
@@ -220,8 +393,8 @@ func (m *Manager) cleanupLoop() {
 
 func (m *Manager) cleanup() {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	now := time.Now()
+	var expired []string
 	for name, r := range m.rooms {
 		lastSeen := r.LastUpdate
 		for _, t := range r.Members {
@@ -231,6 +404,7 @@ func (m *Manager) cleanup() {
 		}
 		if now.Sub(lastSeen) > m.roomTTL {
 			delete(m.rooms, name)
+			expired = append(expired, name)
 		}
 	}
 	for token, mt := range m.mediaTokens {
@@ -238,4 +412,15 @@ func (m *Manager) cleanup() {
 			delete(m.mediaTokens, token)
 		}
 	}
+	for token, rt := range m.remoteTokens {
+		if now.After(rt.ExpiresAt) {
+			delete(m.remoteTokens, token)
+		}
+	}
+	m.mu.Unlock()
+
+	m.ticketStore.sweep()
+	for _, name := range expired {
+		_ = m.store.DeleteRoom(name)
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestJoinRoomAndAuthorize(t *testing.T) {
@@ -60,7 +61,7 @@ func TestUpdateStateHostOnly(t *testing.T) {
 		SourceType:   "web_embed",
 	}
 
-	if _, err := m.UpdateState("room", memberID, state); err != ErrNotHost {
+	if _, err := m.UpdateState("room", memberID, state); err != ErrPermissionDenied {
 		t.Fatalf("member update should be denied, got %v", err)
 	}
 
@@ -112,3 +113,217 @@ func TestResolveMediaPath(t *testing.T) {
 		t.Fatalf("expected ErrMediaForbidden, got %v", err)
 	}
 }
+
+func TestSendChatHistoryAndMute(t *testing.T) {
+	m := NewManager(t.TempDir())
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+	memberID, _, _ := m.JoinRoom("room", "pwd")
+
+	if _, err := m.SendChat("room", memberID, "hello"); err != nil {
+		t.Fatalf("send chat failed: %v", err)
+	}
+	history := m.ChatHistory("room")
+	if len(history) != 1 || history[0].Text != "hello" {
+		t.Fatalf("unexpected chat history: %+v", history)
+	}
+
+	if err := m.MuteMember("room", hostID, memberID, true); err != nil {
+		t.Fatalf("mute member failed: %v", err)
+	}
+	if _, err := m.SendChat("room", memberID, "still here?"); err != ErrMemberMuted {
+		t.Fatalf("expected ErrMemberMuted, got %v", err)
+	}
+
+	if err := m.MuteMember("room", memberID, hostID, true); err != ErrNotHost {
+		t.Fatalf("expected ErrNotHost, got %v", err)
+	}
+}
+
+func TestGrantAndRevokePermissions(t *testing.T) {
+	m := NewManager(t.TempDir())
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+	memberID, _, _ := m.JoinRoom("room", "pwd")
+
+	state := &RoomState{URL: "https://example.com/video", SourceType: "web_embed"}
+	if _, err := m.UpdateState("room", memberID, state); err != ErrPermissionDenied {
+		t.Fatalf("expected ErrPermissionDenied before grant, got %v", err)
+	}
+
+	if err := m.GrantPermissions("room", hostID, memberID, PermControlPlayback); err != nil {
+		t.Fatalf("grant permissions failed: %v", err)
+	}
+	if _, err := m.UpdateState("room", memberID, state); err != nil {
+		t.Fatalf("update should succeed after grant: %v", err)
+	}
+
+	remaining, err := m.RevokePermissions("room", hostID, memberID, PermControlPlayback)
+	if err != nil {
+		t.Fatalf("revoke permissions failed: %v", err)
+	}
+	if remaining.Has(PermControlPlayback) {
+		t.Fatalf("expected PermControlPlayback to be revoked, got %v", remaining)
+	}
+	if _, err := m.UpdateState("room", memberID, state); err != ErrPermissionDenied {
+		t.Fatalf("expected ErrPermissionDenied after revoke, got %v", err)
+	}
+
+	if err := m.GrantPermissions("room", memberID, hostID, PermKick); err != ErrNotHost {
+		t.Fatalf("expected ErrNotHost for non-host grant attempt, got %v", err)
+	}
+}
+
+func TestKickMember(t *testing.T) {
+	m := NewManager(t.TempDir())
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+	memberID, _, _ := m.JoinRoom("room", "pwd")
+	bystanderID, _, _ := m.JoinRoom("room", "pwd")
+
+	if err := m.Kick("room", memberID, bystanderID); err != ErrPermissionDenied {
+		t.Fatalf("expected ErrPermissionDenied for member without can_kick, got %v", err)
+	}
+
+	if err := m.Kick("room", hostID, hostID); err == nil {
+		t.Fatalf("expected host kicking itself to fail")
+	}
+
+	if err := m.Kick("room", hostID, bystanderID); err != nil {
+		t.Fatalf("host kick failed: %v", err)
+	}
+	if _, err := m.Authorize("room", "pwd", bystanderID); err == nil {
+		t.Fatalf("expected kicked member to be removed from the room")
+	}
+
+	if err := m.GrantPermissions("room", hostID, memberID, PermKick); err != nil {
+		t.Fatalf("grant can_kick failed: %v", err)
+	}
+	if err := m.Kick("room", memberID, hostID); err == nil {
+		t.Fatalf("expected kicking the host to fail")
+	}
+}
+
+func TestTicketIssueAndRedeem(t *testing.T) {
+	m := NewManager(t.TempDir())
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+
+	ticket, err := m.IssueTicket("room", hostID)
+	if err != nil {
+		t.Fatalf("issue ticket failed: %v", err)
+	}
+
+	roomName, tempUser, err := m.RedeemTicket(ticket)
+	if err != nil {
+		t.Fatalf("redeem ticket failed: %v", err)
+	}
+	if roomName != "room" || tempUser != hostID {
+		t.Fatalf("unexpected ticket payload: room=%s tempUser=%s", roomName, tempUser)
+	}
+
+	if _, _, err := m.RedeemTicket(ticket); err != ErrTicketNotFound {
+		t.Fatalf("expected ErrTicketNotFound on reuse, got %v", err)
+	}
+}
+
+func TestSendChatRateLimited(t *testing.T) {
+	m := NewManager(t.TempDir())
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+
+	sent := 0
+	for i := 0; i < chatRateBurst+1; i++ {
+		if _, err := m.SendChat("room", hostID, "spam"); err == nil {
+			sent++
+		}
+	}
+	if sent != chatRateBurst {
+		t.Fatalf("expected %d messages to be accepted before rate limiting, got %d", chatRateBurst, sent)
+	}
+}
+
+func TestAutomaticHostHandoff(t *testing.T) {
+	type handoff struct {
+		roomName string
+		newHost  string
+	}
+	changed := make(chan handoff, 1)
+	m := NewManager(t.TempDir(),
+		WithHostGracePeriod(10*time.Millisecond),
+		WithHostChangeHandler(func(roomName, newHostID string) {
+			changed <- handoff{roomName: roomName, newHost: newHostID}
+		}),
+	)
+
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+	memberID, _, _ := m.JoinRoom("room", "pwd")
+
+	m.DisconnectHost("room", hostID)
+
+	select {
+	case got := <-changed:
+		if got.roomName != "room" || got.newHost != memberID {
+			t.Fatalf("expected handoff to %s, got %+v", memberID, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for host change callback")
+	}
+	isHost, err := m.Authorize("room", "pwd", memberID)
+	if err != nil || !isHost {
+		t.Fatalf("expected %s to be host after handoff, isHost=%v err=%v", memberID, isHost, err)
+	}
+}
+
+func TestAutomaticHostHandoffCancelledOnReconnect(t *testing.T) {
+	m := NewManager(t.TempDir(), WithHostGracePeriod(30*time.Millisecond))
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+	m.JoinRoom("room", "pwd")
+
+	m.DisconnectHost("room", hostID)
+	if _, err := m.Authorize("room", "pwd", hostID); err != nil {
+		t.Fatalf("reconnect authorize failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	isHost, err := m.Authorize("room", "pwd", hostID)
+	if err != nil || !isHost {
+		t.Fatalf("expected original host to remain host, isHost=%v err=%v", isHost, err)
+	}
+}
+
+func TestMemoryStoreRoundTrip(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(t.TempDir(), WithStore(store), WithPersistInterval(5*time.Millisecond))
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+
+	state := &RoomState{URL: "https://example.com/video", SourceType: "web_embed"}
+	if _, err := m.UpdateState("room", hostID, state); err != nil {
+		t.Fatalf("update state failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	restored := NewManager(t.TempDir(), WithStore(store))
+	if isHost, err := restored.Authorize("room", "pwd", hostID); err != nil || !isHost {
+		t.Fatalf("expected restored room to recognize original host, isHost=%v err=%v", isHost, err)
+	}
+	if current := restored.CurrentState("room"); current == nil || current.URL != state.URL {
+		t.Fatalf("expected restored room state to match, got %+v", current)
+	}
+}
+
+func TestMemoryStoreRoundTripRemoteToken(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewManager(t.TempDir(), WithStore(store), WithPersistInterval(5*time.Millisecond))
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+
+	token, err := m.ResolveRemoteURL("room", hostID, "https://example.com/video.mp4")
+	if err != nil {
+		t.Fatalf("resolve remote url failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	restored := NewManager(t.TempDir(), WithStore(store))
+	remoteURL, roomName, err := restored.OpenRemoteMedia(token)
+	if err != nil {
+		t.Fatalf("expected remote token to survive restart, got err=%v", err)
+	}
+	if remoteURL != "https://example.com/video.mp4" || roomName != "room" {
+		t.Fatalf("unexpected restored remote token: url=%s room=%s", remoteURL, roomName)
+	}
+}
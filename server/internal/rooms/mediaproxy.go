@@ -0,0 +1,204 @@
+package rooms
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheEntry 是磁盘缓存中一个已完整抓取的上游资源
+type cacheEntry struct {
+	key         string
+	path        string
+	etag        string
+	size        int64
+	contentType string
+}
+
+// MediaProxy 把房主分享的远程 URL（被墙的 CDN、地区限制的直链等）通过本服务器
+// 转发给房间成员，支持 Range 请求，并用一个按 url 建索引的磁盘 LRU 缓存完整抓取
+// 过一次的资源，避免每个成员都重新打一次上游连接。命中缓存前会带上存下的 ETag
+// 向上游发一次条件请求，上游内容变化（ETag 不再匹配）时会废弃缓存重新抓取。
+type MediaProxy struct {
+	client   *http.Client
+	cacheDir string
+	maxBytes int64
+
+	mu    sync.Mutex
+	lru   *list.List
+	index map[string]*list.Element
+	size  int64
+}
+
+func NewMediaProxy(cacheDir string, maxBytes int64) *MediaProxy {
+	_ = os.MkdirAll(cacheDir, 0o755)
+	return &MediaProxy{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		cacheDir: cacheDir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		index:    make(map[string]*list.Element),
+	}
+}
+
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// Serve 把 upstreamURL 的内容流式转发给 w，复用磁盘缓存或透传上游的 Range 响应
+func (p *MediaProxy) Serve(w http.ResponseWriter, r *http.Request, upstreamURL string) error {
+	key := cacheKey(upstreamURL)
+
+	if entry := p.lookup(key); entry != nil {
+		if p.revalidate(r.Context(), upstreamURL, entry) {
+			f, err := os.Open(entry.path)
+			if err == nil {
+				defer f.Close()
+				if entry.contentType != "" {
+					w.Header().Set("Content-Type", entry.contentType)
+				}
+				modTime := time.Now()
+				if info, statErr := f.Stat(); statErr == nil {
+					modTime = info.ModTime()
+				}
+				http.ServeContent(w, r, "", modTime, f)
+				return nil
+			}
+		}
+		p.evict(key)
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, upstreamURL, nil)
+	if err != nil {
+		return err
+	}
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+
+	for _, h := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	if resp.StatusCode == http.StatusOK {
+		p.cacheFullResponse(key, resp, w)
+		return nil
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// cacheFullResponse 把完整的（非 Range）上游响应边转发给客户端边落盘缓存，供
+// 之后的 Range 请求直接从本地命中。临时文件名带随机后缀，避免同一个 URL 的并发
+// 首次请求互相争抢同一个 tmp 路径，导致 io.Copy/os.Rename 踩到彼此写了一半的文件
+func (p *MediaProxy) cacheFullResponse(key string, resp *http.Response, w http.ResponseWriter) {
+	tmpFile, err := os.CreateTemp(p.cacheDir, key+".*.tmp")
+	if err != nil {
+		_, _ = io.Copy(w, resp.Body)
+		return
+	}
+	tmpPath := tmpFile.Name()
+	written, copyErr := io.Copy(io.MultiWriter(w, tmpFile), resp.Body)
+	tmpFile.Close()
+	if copyErr != nil || written == 0 {
+		os.Remove(tmpPath)
+		return
+	}
+
+	finalPath := filepath.Join(p.cacheDir, key)
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+	p.store(&cacheEntry{
+		key:         key,
+		path:        finalPath,
+		etag:        resp.Header.Get("ETag"),
+		size:        written,
+		contentType: resp.Header.Get("Content-Type"),
+	})
+}
+
+// revalidate 判断磁盘缓存中的 entry 相对上游是否仍然新鲜：entry 没有记录
+// ETag（上游从不返回 ETag）时直接信任缓存；否则带上 If-None-Match 发一次 HEAD
+// 请求，304 代表未变化可以继续使用缓存，200（ETag 已变化）代表过期需要废弃
+// 重新抓取；HEAD 请求本身失败时退回信任缓存，避免上游抖动导致缓存完全失效。
+func (p *MediaProxy) revalidate(ctx context.Context, upstreamURL string, entry *cacheEntry) bool {
+	if entry.etag == "" {
+		return true
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, upstreamURL, nil)
+	if err != nil {
+		return true
+	}
+	req.Header.Set("If-None-Match", entry.etag)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusNotModified
+}
+
+func (p *MediaProxy) lookup(key string) *cacheEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	el, ok := p.index[key]
+	if !ok {
+		return nil
+	}
+	p.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+func (p *MediaProxy) store(entry *cacheEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.index[entry.key]; ok {
+		p.lru.Remove(el)
+		p.size -= el.Value.(*cacheEntry).size
+	}
+	el := p.lru.PushFront(entry)
+	p.index[entry.key] = el
+	p.size += entry.size
+
+	for p.size > p.maxBytes && p.lru.Len() > 0 {
+		back := p.lru.Back()
+		oldest := back.Value.(*cacheEntry)
+		p.lru.Remove(back)
+		delete(p.index, oldest.key)
+		p.size -= oldest.size
+		os.Remove(oldest.path)
+	}
+}
+
+func (p *MediaProxy) evict(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.index[key]; ok {
+		p.lru.Remove(el)
+		p.size -= el.Value.(*cacheEntry).size
+		delete(p.index, key)
+	}
+}
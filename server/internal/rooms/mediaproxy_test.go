@@ -0,0 +1,107 @@
+package rooms
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMediaProxyServesAndCachesFullResponse(t *testing.T) {
+	const body = "hello upstream"
+	var requests int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	proxy := NewMediaProxy(t.TempDir(), 1<<20)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/media/tok", nil)
+		if err := proxy.Serve(rec, req, upstream.URL); err != nil {
+			t.Fatalf("serve %d failed: %v", i, err)
+		}
+		if rec.Body.String() != body {
+			t.Fatalf("serve %d: unexpected body %q", i, rec.Body.String())
+		}
+	}
+	if requests != 2 {
+		t.Fatalf("expected upstream GET once and one HEAD revalidation, got %d requests", requests)
+	}
+}
+
+func TestMediaProxyRangePassthrough(t *testing.T) {
+	const full = "0123456789"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader := r.Header.Get("Range")
+		if rangeHeader == "" {
+			t.Fatalf("expected Range header to be forwarded")
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 2-4/%d", len(full)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(full[2:5]))
+	}))
+	defer upstream.Close()
+
+	proxy := NewMediaProxy(t.TempDir(), 1<<20)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/media/tok", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	if err := proxy.Serve(rec, req, upstream.URL); err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	if rec.Body.String() != full[2:5] {
+		t.Fatalf("unexpected range body %q", rec.Body.String())
+	}
+}
+
+func TestMediaProxyRevalidatesStaleCache(t *testing.T) {
+	etag := `"v1"`
+	body := "first version"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			if inm == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	proxy := NewMediaProxy(t.TempDir(), 1<<20)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/media/tok", nil)
+	if err := proxy.Serve(rec, req, upstream.URL); err != nil {
+		t.Fatalf("initial serve failed: %v", err)
+	}
+
+	// Upstream content changed: bump the ETag so the cached entry should be invalidated.
+	etag = `"v2"`
+	body = "second version"
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/media/tok", nil)
+	if err := proxy.Serve(rec, req, upstream.URL); err != nil {
+		t.Fatalf("second serve failed: %v", err)
+	}
+	if rec.Body.String() != body {
+		t.Fatalf("expected stale cache to be refreshed, got %q", rec.Body.String())
+	}
+}
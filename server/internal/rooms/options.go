@@ -0,0 +1,56 @@
+package rooms
+
+import "time"
+
+// Option 用于在构造 Manager 时覆盖默认配置
+type Option func(*Manager)
+
+// WithMediaProxyCacheSize 设置远程媒体代理磁盘缓存的容量上限（字节）
+func WithMediaProxyCacheSize(maxBytes int64) Option {
+	return func(m *Manager) {
+		m.mediaProxyCacheMax = maxBytes
+	}
+}
+
+// WithICEServers 配置用于 WebRTC 信令的 STUN/TURN 服务器列表
+func WithICEServers(servers []ICEServer) Option {
+	return func(m *Manager) {
+		m.iceServers = servers
+	}
+}
+
+// WithTicketTTL 配置 WebSocket 接入 ticket 的有效期
+func WithTicketTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.ticketStore = NewTicketStore(ttl)
+	}
+}
+
+// WithStore 配置房间状态的持久化后端，默认使用不跨重启保留数据的 MemoryStore
+func WithStore(store Store) Option {
+	return func(m *Manager) {
+		m.store = store
+	}
+}
+
+// WithHostGracePeriod 配置房主断线后、自动交接给下一位成员前的宽限时长
+func WithHostGracePeriod(d time.Duration) Option {
+	return func(m *Manager) {
+		m.hostGrace = d
+	}
+}
+
+// WithHostChangeHandler 注册一个回调，在自动房主交接完成后被调用，
+// 供上层（如 ws hub）据此广播 host_changed 消息
+func WithHostChangeHandler(fn HostChangeFunc) Option {
+	return func(m *Manager) {
+		m.onHostChanged = fn
+	}
+}
+
+// WithPersistInterval 配置脏房间合并落盘的周期
+func WithPersistInterval(d time.Duration) Option {
+	return func(m *Manager) {
+		m.persistInterval = d
+	}
+}
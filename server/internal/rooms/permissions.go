@@ -0,0 +1,122 @@
+package rooms
+
+import "errors"
+
+// Permissions 是按位存储的成员能力集合，替代早期单一的 HostID == tempUser 判断
+type Permissions uint16
+
+const (
+	PermControlPlayback Permissions = 1 << iota
+	PermShareMedia
+	PermChat
+	PermDanmaku
+	PermKick
+)
+
+// AllPermissions 是房间创建者默认持有的全部权限
+const AllPermissions = PermControlPlayback | PermShareMedia | PermChat | PermDanmaku | PermKick
+
+// defaultMemberPermissions 是非创建者加入房间时的默认权限：可以聊天发弹幕，但不能控制播放
+const defaultMemberPermissions = PermChat | PermDanmaku
+
+// ErrPermissionDenied 表示调用者持有的权限不满足该操作所需的具体位
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Has 判断 p 是否包含 perm 对应的位
+func (p Permissions) Has(perm Permissions) bool {
+	return p&perm != 0
+}
+
+var permissionNames = map[string]Permissions{
+	"can_control_playback": PermControlPlayback,
+	"can_share_media":      PermShareMedia,
+	"can_chat":             PermChat,
+	"can_danmaku":          PermDanmaku,
+	"can_kick":             PermKick,
+}
+
+// ParsePermission 把 ws 消息（grant_permissions/revoke_permissions）里使用的字符串名解析为对应的位
+func ParsePermission(name string) (Permissions, bool) {
+	p, ok := permissionNames[name]
+	return p, ok
+}
+
+// hasPermission 要求调用方已持有 m.mu，不做加锁
+func (r *room) hasPermission(tempUser string, perm Permissions) bool {
+	return r.Permissions[tempUser].Has(perm)
+}
+
+// HasPermission 是 hasPermission 的加锁版本，供 main.go 等包外调用方使用
+func (m *Manager) HasPermission(roomName, tempUser string, perm Permissions) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return false
+	}
+	return r.hasPermission(tempUser, perm)
+}
+
+// GrantPermissions 仅房主可用，为 target 追加权限位
+func (m *Manager) GrantPermissions(roomName, hostID, target string, perms Permissions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if r.HostID != hostID {
+		return ErrNotHost
+	}
+	if _, exists := r.Members[target]; !exists {
+		return errMemberNotInRoom(target)
+	}
+	r.Permissions[target] |= perms
+	m.markDirty(roomName)
+	return nil
+}
+
+// RevokePermissions 仅房主可用，撤销 target 的权限位，返回撤销后剩余的权限集合
+func (m *Manager) RevokePermissions(roomName, hostID, target string, perms Permissions) (Permissions, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return 0, ErrRoomNotFound
+	}
+	if r.HostID != hostID {
+		return 0, ErrNotHost
+	}
+	if _, exists := r.Members[target]; !exists {
+		return 0, errMemberNotInRoom(target)
+	}
+	r.Permissions[target] &^= perms
+	m.markDirty(roomName)
+	return r.Permissions[target], nil
+}
+
+// Kick 把 target 移出房间，调用者需持有 can_kick 权限；房主不能被踢出，
+// 只能通过正常断线 + 自动交接让出位置
+func (m *Manager) Kick(roomName, actor, target string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if !r.hasPermission(actor, PermKick) {
+		return ErrPermissionDenied
+	}
+	if _, exists := r.Members[target]; !exists {
+		return errMemberNotInRoom(target)
+	}
+	if target == r.HostID {
+		return errors.New("cannot kick the host")
+	}
+	delete(r.Members, target)
+	delete(r.JoinedAt, target)
+	delete(r.Permissions, target)
+	delete(r.Muted, target)
+	m.markDirty(roomName)
+	return nil
+}
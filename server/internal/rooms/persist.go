@@ -0,0 +1,138 @@
+package rooms
+
+import "time"
+
+const defaultPersistInterval = 2 * time.Second
+
+// markDirty 要求调用方已持有 m.mu，标记一个房间需要在下次 flush 时落盘
+func (m *Manager) markDirty(roomName string) {
+	m.dirtyMu.Lock()
+	defer m.dirtyMu.Unlock()
+	if m.dirty == nil {
+		m.dirty = make(map[string]bool)
+	}
+	m.dirty[roomName] = true
+}
+
+func (m *Manager) persistLoop() {
+	ticker := time.NewTicker(m.persistInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.flushDirty()
+	}
+}
+
+// flushDirty 把一批脏房间一次性落盘，让频繁的 host_update 调用合并成一次磁盘写入
+// 而不是每一帧都触发一次 I/O
+func (m *Manager) flushDirty() {
+	m.dirtyMu.Lock()
+	if len(m.dirty) == 0 {
+		m.dirtyMu.Unlock()
+		return
+	}
+	names := make([]string, 0, len(m.dirty))
+	for name := range m.dirty {
+		names = append(names, name)
+	}
+	m.dirty = make(map[string]bool)
+	m.dirtyMu.Unlock()
+
+	for _, name := range names {
+		if snap, ok := m.snapshot(name); ok {
+			_ = m.store.SaveRoom(snap)
+		} else {
+			_ = m.store.DeleteRoom(name)
+		}
+	}
+}
+
+func (m *Manager) snapshot(roomName string) (RoomSnapshot, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return RoomSnapshot{}, false
+	}
+
+	perms := make(map[string]Permissions, len(r.Permissions))
+	for user, p := range r.Permissions {
+		perms[user] = p
+	}
+	joinedAt := make(map[string]time.Time, len(r.JoinedAt))
+	for user, t := range r.JoinedAt {
+		joinedAt[user] = t
+	}
+	var tokens []MediaTokenSnapshot
+	for _, t := range m.mediaTokens {
+		if t.RoomName == roomName {
+			tokens = append(tokens, MediaTokenSnapshot{Token: t.Token, Path: t.Path, ExpiresAt: t.ExpiresAt})
+		}
+	}
+	var remoteTokens []RemoteMediaTokenSnapshot
+	for _, t := range m.remoteTokens {
+		if t.RoomName == roomName {
+			remoteTokens = append(remoteTokens, RemoteMediaTokenSnapshot{Token: t.Token, URL: t.URL, ExpiresAt: t.ExpiresAt})
+		}
+	}
+
+	return RoomSnapshot{
+		Name:         r.Name,
+		Password:     r.Password,
+		HostID:       r.HostID,
+		State:        r.State,
+		Permissions:  perms,
+		JoinedAt:     joinedAt,
+		MediaTokens:  tokens,
+		RemoteTokens: remoteTokens,
+		SavedAt:      time.Now(),
+	}, true
+}
+
+// loadFromStore 在启动时从持久化后端重建房间；成员集合由权限表的成员名重建，
+// 因为重启前已加入的用户都在该表中留有一条记录，而真实的加入顺序则从快照里的
+// JoinedAt 还原，供 earliestMember 在自动交接时挑选最早加入的成员
+func (m *Manager) loadFromStore() {
+	snapshots, err := m.store.LoadRooms()
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, snap := range snapshots {
+		r := &room{
+			Name:        snap.Name,
+			Password:    snap.Password,
+			HostID:      snap.HostID,
+			State:       snap.State,
+			Members:     map[string]time.Time{},
+			JoinedAt:    map[string]time.Time{},
+			Muted:       map[string]bool{},
+			Permissions: snap.Permissions,
+		}
+		for user := range snap.Permissions {
+			r.Members[user] = snap.SavedAt
+			joinedAt := snap.JoinedAt[user]
+			if joinedAt.IsZero() {
+				joinedAt = snap.SavedAt
+			}
+			r.JoinedAt[user] = joinedAt
+		}
+		m.rooms[snap.Name] = r
+		for _, t := range snap.MediaTokens {
+			m.mediaTokens[t.Token] = &mediaToken{
+				Token:     t.Token,
+				Path:      t.Path,
+				RoomName:  snap.Name,
+				ExpiresAt: t.ExpiresAt,
+			}
+		}
+		for _, t := range snap.RemoteTokens {
+			m.remoteTokens[t.Token] = &remoteMediaToken{
+				Token:     t.Token,
+				URL:       t.URL,
+				RoomName:  snap.Name,
+				ExpiresAt: t.ExpiresAt,
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package rooms
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// validateRemoteURL 防止 SSRF：房主/被授予 can_share_media 的成员登记的远程
+// URL 不允许指向回环、链路本地或私有地址段，包括主机名解析之后仍落在这些网段
+// 里的情况（如云厂商的实例元数据地址），否则代理会替攻击者把内网响应读出来
+func validateRemoteURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("url missing host")
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		resolved, err := net.LookupIP(host)
+		if err != nil {
+			return fmt.Errorf("resolve host: %w", err)
+		}
+		ips = append(ips, resolved...)
+	}
+	for _, ip := range ips {
+		if isDisallowedRemoteIP(ip) {
+			return fmt.Errorf("url host %q resolves to a disallowed address", host)
+		}
+	}
+	return nil
+}
+
+func isDisallowedRemoteIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsPrivate()
+}
@@ -0,0 +1,33 @@
+package rooms
+
+import "testing"
+
+func TestValidateRemoteURLBlocksPrivateAndLoopback(t *testing.T) {
+	blocked := []string{
+		"http://127.0.0.1/secret",
+		"http://localhost/secret",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/internal",
+		"http://192.168.1.1/internal",
+	}
+	for _, raw := range blocked {
+		if err := validateRemoteURL(raw); err == nil {
+			t.Fatalf("expected %q to be rejected as an internal address", raw)
+		}
+	}
+}
+
+func TestValidateRemoteURLAllowsPublicAddress(t *testing.T) {
+	if err := validateRemoteURL("http://8.8.8.8/video.mp4"); err != nil {
+		t.Fatalf("expected public IP to be allowed, got %v", err)
+	}
+}
+
+func TestResolveRemoteURLRejectsInternalAddress(t *testing.T) {
+	m := NewManager(t.TempDir())
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+
+	if _, err := m.ResolveRemoteURL("room", hostID, "http://169.254.169.254/latest/meta-data/"); err == nil {
+		t.Fatalf("expected internal url to be rejected")
+	}
+}
@@ -0,0 +1,77 @@
+package rooms
+
+import (
+	"sync"
+	"time"
+)
+
+// MediaTokenSnapshot 是某个媒体令牌在持久化时的快照
+type MediaTokenSnapshot struct {
+	Token     string
+	Path      string
+	ExpiresAt time.Time
+}
+
+// RemoteMediaTokenSnapshot 是某个远程 URL 代理令牌在持久化时的快照
+type RemoteMediaTokenSnapshot struct {
+	Token     string
+	URL       string
+	ExpiresAt time.Time
+}
+
+// RoomSnapshot 是一个房间在持久化时的快照视图，足以在服务重启后重建房间
+type RoomSnapshot struct {
+	Name         string
+	Password     string
+	HostID       string
+	State        *RoomState
+	Permissions  map[string]Permissions
+	JoinedAt     map[string]time.Time
+	MediaTokens  []MediaTokenSnapshot
+	RemoteTokens []RemoteMediaTokenSnapshot
+	SavedAt      time.Time
+}
+
+// Store 是房间持久化的可插拔后端，默认使用不跨重启保留数据的 MemoryStore
+type Store interface {
+	SaveRoom(snapshot RoomSnapshot) error
+	LoadRooms() ([]RoomSnapshot, error)
+	DeleteRoom(name string) error
+	Close() error
+}
+
+// MemoryStore 只把快照保存在内存里，进程退出后数据即丢失
+type MemoryStore struct {
+	mu        sync.Mutex
+	snapshots map[string]RoomSnapshot
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{snapshots: make(map[string]RoomSnapshot)}
+}
+
+func (s *MemoryStore) SaveRoom(snapshot RoomSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[snapshot.Name] = snapshot
+	return nil
+}
+
+func (s *MemoryStore) LoadRooms() ([]RoomSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]RoomSnapshot, 0, len(s.snapshots))
+	for _, snap := range s.snapshots {
+		out = append(out, snap)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) DeleteRoom(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.snapshots, name)
+	return nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
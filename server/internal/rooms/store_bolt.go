@@ -0,0 +1,68 @@
+package rooms
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var roomsBucket = []byte("rooms")
+
+// BoltStore 用嵌入式 bbolt 数据库持久化房间状态，适合单机部署下服务重启后
+// 恢复房间，不需要额外起一个数据库进程
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(roomsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) SaveRoom(snapshot RoomSnapshot) error {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).Put([]byte(snapshot.Name), payload)
+	})
+}
+
+func (s *BoltStore) LoadRooms() ([]RoomSnapshot, error) {
+	var out []RoomSnapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).ForEach(func(_, v []byte) error {
+			var snap RoomSnapshot
+			if err := json.Unmarshal(v, &snap); err != nil {
+				return err
+			}
+			out = append(out, snap)
+			return nil
+		})
+	})
+	return out, err
+}
+
+func (s *BoltStore) DeleteRoom(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).Delete([]byte(name))
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
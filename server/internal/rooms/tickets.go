@@ -0,0 +1,77 @@
+package rooms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTicketNotFound 表示该 ticket 不存在、已被使用或已过期
+var ErrTicketNotFound = errors.New("ticket not found or expired")
+
+type joinTicket struct {
+	RoomName  string
+	TempUser  string
+	ExpiresAt time.Time
+}
+
+// TicketStore 签发并校验短期有效、一次性的 WebSocket 接入凭证，
+// 让密码不必再出现在 /ws 的查询字符串或服务器访问日志里
+type TicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]*joinTicket
+	ttl     time.Duration
+}
+
+func NewTicketStore(ttl time.Duration) *TicketStore {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &TicketStore{tickets: make(map[string]*joinTicket), ttl: ttl}
+}
+
+// Issue 签发一个绑定到 (roomName, tempUser) 的一次性 ticket
+func (s *TicketStore) Issue(roomName, tempUser string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickets[token] = &joinTicket{
+		RoomName:  roomName,
+		TempUser:  tempUser,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	return token, nil
+}
+
+// Redeem 校验并消费一个 ticket，每个 ticket 只能成功兑换一次
+func (s *TicketStore) Redeem(token string) (roomName, tempUser string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tickets[token]
+	if !ok {
+		return "", "", ErrTicketNotFound
+	}
+	delete(s.tickets, token)
+	if time.Now().After(t.ExpiresAt) {
+		return "", "", ErrTicketNotFound
+	}
+	return t.RoomName, t.TempUser, nil
+}
+
+func (s *TicketStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for token, t := range s.tickets {
+		if now.After(t.ExpiresAt) {
+			delete(s.tickets, token)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package rooms
+
+// ICEServer 描述一个 STUN/TURN 服务器，随 /api/room/rtc-config 下发给客户端，
+// 避免客户端硬编码信令服务器地址
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// ICEServers 返回当前配置的 ICE 服务器列表
+func (m *Manager) ICEServers() []ICEServer {
+	return m.iceServers
+}
+
+// MarkPublishing 记录该成员正在发布一路摄像头/屏幕共享流，并把它记为房间当前
+// 的发布者，供 ws hub 按 tempUser（而非 isHost）路由 offer/answer/ICE
+func (m *Manager) MarkPublishing(roomName, tempUser string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if r.Publishing == nil {
+		r.Publishing = make(map[string]bool)
+	}
+	r.Publishing[tempUser] = true
+	r.Publisher = tempUser
+	return nil
+}
+
+// CurrentPublisher 返回房间当前正在发布流的成员 tempUser，没有成员在发布时返回空串
+func (m *Manager) CurrentPublisher(roomName string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return ""
+	}
+	return r.Publisher
+}
+
+// MarkSubscribing 记录该成员正在订阅房间当前发布者的流
+func (m *Manager) MarkSubscribing(roomName, tempUser string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if r.Subscribing == nil {
+		r.Subscribing = make(map[string]bool)
+	}
+	r.Subscribing[tempUser] = true
+	return nil
+}
+
+// ClearStream 在成员断开连接或被收回共享权限时清理其发布/订阅状态
+func (m *Manager) ClearStream(roomName, tempUser string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[roomName]
+	if !ok {
+		return
+	}
+	delete(r.Publishing, tempUser)
+	delete(r.Subscribing, tempUser)
+	if r.Publisher == tempUser {
+		r.Publisher = ""
+	}
+}
@@ -0,0 +1,59 @@
+package rooms
+
+import "testing"
+
+func TestMarkPublishingAndSubscribing(t *testing.T) {
+	m := NewManager(t.TempDir())
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+	memberID, _, _ := m.JoinRoom("room", "pwd")
+
+	if err := m.MarkPublishing("room", hostID); err != nil {
+		t.Fatalf("mark publishing failed: %v", err)
+	}
+	if err := m.MarkSubscribing("room", memberID); err != nil {
+		t.Fatalf("mark subscribing failed: %v", err)
+	}
+
+	r := m.rooms["room"]
+	if !r.Publishing[hostID] {
+		t.Fatalf("expected host to be marked publishing")
+	}
+	if !r.Subscribing[memberID] {
+		t.Fatalf("expected member to be marked subscribing")
+	}
+
+	m.ClearStream("room", hostID)
+	m.ClearStream("room", memberID)
+	if r.Publishing[hostID] || r.Subscribing[memberID] {
+		t.Fatalf("expected stream state to be cleared")
+	}
+
+	if err := m.MarkPublishing("missing-room", hostID); err != ErrRoomNotFound {
+		t.Fatalf("expected ErrRoomNotFound, got %v", err)
+	}
+}
+
+func TestCurrentPublisherTracksNonHostMember(t *testing.T) {
+	m := NewManager(t.TempDir())
+	hostID, _, _ := m.JoinRoom("room", "pwd")
+	memberID, _, _ := m.JoinRoom("room", "pwd")
+
+	if publisher := m.CurrentPublisher("room"); publisher != "" {
+		t.Fatalf("expected no publisher initially, got %q", publisher)
+	}
+
+	if err := m.GrantPermissions("room", hostID, memberID, PermShareMedia); err != nil {
+		t.Fatalf("grant can_share_media failed: %v", err)
+	}
+	if err := m.MarkPublishing("room", memberID); err != nil {
+		t.Fatalf("mark publishing failed: %v", err)
+	}
+	if publisher := m.CurrentPublisher("room"); publisher != memberID {
+		t.Fatalf("expected publisher to be the granted member %s, got %q", memberID, publisher)
+	}
+
+	m.ClearStream("room", memberID)
+	if publisher := m.CurrentPublisher("room"); publisher != "" {
+		t.Fatalf("expected publisher to be cleared, got %q", publisher)
+	}
+}